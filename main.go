@@ -1,26 +1,83 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"hosts-server/pkg/hosts"
+	"hosts-server/pkg/scheduler"
 )
 
 // 命令行参数
 var (
-	serverMode = flag.Bool("server", true, "启动HTTP服务模式")
-	port       = flag.String("port", "8585", "HTTP服务端口")
-	domainFile = flag.String("domain", "domain.txt", "域名文件路径")
-	outputFile = flag.String("output", "hosts", "输出hosts文件路径")
-	help       = flag.Bool("help", false, "显示帮助信息")
+	serverMode      = flag.Bool("server", true, "启动HTTP服务模式")
+	port            = flag.String("port", "8585", "HTTP服务端口")
+	domainFile      = flag.String("domain", "domain.txt", "域名文件路径")
+	outputFile      = flag.String("output", "hosts", "输出hosts文件路径")
+	scheduleConfig  = flag.String("schedule-config", "", "调度配置文件路径(YAML/JSON)，指定后按域名各自节奏检测并输出多种格式，不指定则沿用每3小时整体检测一次的默认行为")
+	resolvers       = flag.String("resolvers", "", "DNS解析器列表，逗号分隔，支持udp://、tls://、https://前缀，不指定则使用默认的UDP解析器")
+	mode            = flag.String("mode", "ipv4", "IP解析策略: ipv4/ipv6/dual/fastest")
+	certMinDays     = flag.Int("cert-min-days", hosts.DefaultCertMinDaysValid, "候选IP的TLS证书最少剩余有效天数，低于该值的候选会被跳过")
+	geoIPPath       = flag.String("geoip", "", "MaxMind GeoLite2 City mmdb数据库路径，用于为候选IP标注国家/地区")
+	ip2regionPath   = flag.String("ip2region", "", "ip2region xdb数据库路径，同时配置-geoip时优先使用")
+	preferCountries = flag.String("prefer-countries", "", "优先选择的国家/地区代码，逗号分隔，如 HK,JP,US")
+	help            = flag.Bool("help", false, "显示帮助信息")
 )
 
+// buildDetectorOptions 把命令行参数转换为HostsDetector的Option列表
+func buildDetectorOptions() ([]hosts.Option, error) {
+	var opts []hosts.Option
+
+	if *resolvers != "" {
+		var list []hosts.Resolver
+		for _, addr := range strings.Split(*resolvers, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr == "" {
+				continue
+			}
+			r, err := hosts.NewResolver(addr)
+			if err != nil {
+				return nil, fmt.Errorf("解析resolvers中的 %s 失败: %v", addr, err)
+			}
+			list = append(list, r)
+		}
+		opts = append(opts, hosts.WithResolvers(list))
+	}
+
+	parsedMode, err := hosts.ParseMode(*mode)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, hosts.WithMode(parsedMode))
+
+	if *certMinDays >= 0 {
+		opts = append(opts, hosts.WithCertMinDaysValid(*certMinDays))
+	}
+
+	if *geoIPPath != "" {
+		opts = append(opts, hosts.WithGeoIPPath(*geoIPPath))
+	}
+	if *ip2regionPath != "" {
+		opts = append(opts, hosts.WithIP2RegionPath(*ip2regionPath))
+	}
+	if *preferCountries != "" {
+		var countries []string
+		for _, c := range strings.Split(*preferCountries, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				countries = append(countries, c)
+			}
+		}
+		opts = append(opts, hosts.WithPreferCountries(countries))
+	}
+
+	return opts, nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -48,6 +105,13 @@ func printHelp() {
 	fmt.Printf("  -port string    HTTP服务端口 (默认 \"8585\")\n")
 	fmt.Printf("  -domain string  域名文件路径 (默认 \"domain.txt\")\n")
 	fmt.Printf("  -output string  输出hosts文件路径 (默认 \"hosts\")\n")
+	fmt.Printf("  -schedule-config string  调度配置文件路径(YAML/JSON)，按域名各自节奏检测\n")
+	fmt.Printf("  -resolvers string  DNS解析器列表，逗号分隔，支持udp://、tls://、https://前缀\n")
+	fmt.Printf("  -mode string    IP解析策略: ipv4/ipv6/dual/fastest (默认 \"ipv4\")\n")
+	fmt.Printf("  -cert-min-days int  候选IP证书最少剩余有效天数 (默认 %d)\n", hosts.DefaultCertMinDaysValid)
+	fmt.Printf("  -geoip string   MaxMind GeoLite2 City mmdb数据库路径\n")
+	fmt.Printf("  -ip2region string  ip2region xdb数据库路径，优先于-geoip\n")
+	fmt.Printf("  -prefer-countries string  优先选择的国家/地区代码，逗号分隔，如 HK,JP,US\n")
 	fmt.Printf("  -help           显示此帮助信息\n\n")
 	fmt.Printf("示例:\n")
 	fmt.Printf("  %s                          # 单次检测并保存hosts文件\n", os.Args[0])
@@ -61,155 +125,89 @@ func runSingleDetection() {
 	currentTime := time.Now().Format("2006-01-02 15:04:05")
 	fmt.Printf("%s - 开始执行脚本\n", currentTime)
 
+	opts, err := buildDetectorOptions()
+	if err != nil {
+		log.Fatalf("解析检测参数失败: %v", err)
+	}
+
 	// 使用指定配置运行检测
-	detector := hosts.NewHostsDetector(*domainFile, *outputFile)
+	detector := hosts.NewHostsDetector(*domainFile, *outputFile, opts...)
 	if err := detector.DetectAndSave(); err != nil {
 		log.Fatalf("检测失败: %v", err)
 	}
 }
 
-// startServer 启动HTTP服务
+// startServer 启动HTTP服务：路由交给hosts.HostsService（基于gin），
+// 这样/api/v1/*和/metrics才是同一个进程里真正对外提供的接口
 func startServer() {
 	fmt.Printf("启动Hosts检测服务...\n")
 	fmt.Printf("服务地址: http://localhost:%s\n\n", *port)
 
-	// 创建hosts检测器
-	detector := hosts.NewHostsDetector(*domainFile, *outputFile)
+	opts, err := buildDetectorOptions()
+	if err != nil {
+		log.Fatalf("解析检测参数失败: %v", err)
+	}
 
-	// 定时检测：启动时立即检测一次，之后每3小时检测
-	go func() {
-		fmt.Printf("[定时任务] 启动时进行一次检测并写入文件...\n")
-		if err := detector.DetectAndSave(); err != nil {
-			log.Printf("[定时任务] 启动检测失败: %v\n", err)
-		}
+	var service *hosts.HostsService
 
-		ticker := time.NewTicker(3 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			fmt.Printf("[定时任务] 每3小时检测一次并写入文件...\n")
-			if err := detector.DetectAndSave(); err != nil {
-				log.Printf("[定时任务] 检测失败: %v\n", err)
-			}
-		}
-	}()
-
-	// 状态接口
-	http.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-
-		response := map[string]interface{}{
-			"code":    200,
-			"message": "服务正常运行",
-			"data": map[string]interface{}{
-				"service":     "Hosts 检测服务",
-				"version":     "1.0.0",
-				"timestamp":   time.Now().Format("2006-01-02 15:04:05"),
-				"domain_file": *domainFile,
-				"output_file": *outputFile,
-			},
+	if *scheduleConfig != "" {
+		// 按调度配置中每个域名各自的节奏检测，并输出到配置中指定的多种格式文件
+		cfg, err := scheduler.LoadConfig(*scheduleConfig)
+		if err != nil {
+			log.Fatalf("加载调度配置失败: %v", err)
 		}
 
-		json.NewEncoder(w).Encode(response)
-	})
-
-	// 健康检查
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-
-		response := map[string]interface{}{
-			"status":    "healthy",
-			"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+		cfgOpts, err := cfg.DetectorOptions()
+		if err != nil {
+			log.Fatalf("解析调度配置中的检测参数失败: %v", err)
 		}
+		detector := hosts.NewHostsDetector(*domainFile, *outputFile, append(opts, cfgOpts...)...)
 
-		json.NewEncoder(w).Encode(response)
-	})
-
-
-	// 获取当前hosts文件内容（原始文本）
-	http.HandleFunc("/hosts", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Content-Disposition", "attachment; filename=hosts")
-
-		content, err := os.ReadFile(*outputFile)
+		sched, err := scheduler.New(detector, cfg)
 		if err != nil {
-			http.Error(w, "读取hosts文件失败: "+err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("初始化调度器失败: %v", err)
 		}
 
-		w.Write(content)
-	})
-
-	// 实时检测并获取hosts内容（JSON格式）
-	http.HandleFunc("/hosts/json", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		fmt.Printf("[调度任务] 已加载调度配置 %s，共 %d 个域名\n", *scheduleConfig, len(cfg.Domains))
+		sched.Run(make(chan struct{}))
 
-		fmt.Printf("开始实时检测hosts（JSON格式）...\n")
+		// 调度器已经按每个域名自己的Interval/ChangeThreshold在后台检测，hosts相关接口
+		// 改读它的结果快照，不再对detector发起另一套同步检测，和调度goroutine抢DNS/
+		// 探测/证书资源、也不会绕开调度器的写出节奏
+		service = hosts.NewHostsServiceFromDetector(detector).WithResultsProvider(sched.Results)
+	} else {
+		service = hosts.NewHostsService(*domainFile, *outputFile, opts...)
 
-		results, err := detector.DetectHosts()
-		if err != nil {
-			response := map[string]interface{}{
-				"code":    500,
-				"message": "检测hosts失败: " + err.Error(),
+		// 定时检测：启动时立即检测一次，之后每3小时检测
+		go func() {
+			fmt.Printf("[定时任务] 启动时进行一次检测并写入文件...\n")
+			if err := service.Detector().DetectAndSave(); err != nil {
+				log.Printf("[定时任务] 启动检测失败: %v\n", err)
 			}
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(response)
-			return
-		}
-
-		// 生成hosts内容
-		content := detector.GenerateHostsContent(results)
 
-		// 统计成功数量
-		successCount := 0
-		for _, result := range results {
-			if result.Error == "" {
-				successCount++
+			ticker := time.NewTicker(3 * time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				fmt.Printf("[定时任务] 每3小时检测一次并写入文件...\n")
+				if err := service.Detector().DetectAndSave(); err != nil {
+					log.Printf("[定时任务] 检测失败: %v\n", err)
+				}
 			}
-		}
-
-		// 保存到文件
-		if err := detector.WriteHostsFile(results); err != nil {
-			w.Header().Set("X-Save-Error", err.Error())
-		}
-
-		response := map[string]interface{}{
-			"code":    200,
-			"message": "获取hosts成功",
-			"data": map[string]interface{}{
-				"content":    content,
-				"results":    results,
-				"updated_at": time.Now().Format("2006-01-02 15:04:05"),
-				"total":      len(results),
-				"success":    successCount,
-			},
-		}
-
-		fmt.Printf("实时检测完成，成功: %d/%d\n", successCount, len(results))
-		json.NewEncoder(w).Encode(response)
-	})
-
-	// 根路径重定向
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			http.Redirect(w, r, "/status", http.StatusMovedPermanently)
-			return
-		}
-		http.NotFound(w, r)
-	})
+		}()
+	}
 
 	fmt.Printf("可用的API接口:\n")
-	fmt.Printf("  GET  /status      - 服务状态\n")
-	fmt.Printf("  GET  /hosts       - 获取当前hosts文件（原始文本）\n")
-	fmt.Printf("  GET  /hosts/json  - 实时检测hosts（JSON格式）\n")
-	fmt.Printf("  GET  /health      - 健康检查\n")
+	fmt.Printf("  GET  /api/v1/status     - 服务状态\n")
+	fmt.Printf("  GET  /api/v1/hosts      - 获取hosts（JSON格式）\n")
+	fmt.Printf("  GET  /api/v1/hosts/raw  - 获取hosts（原始文本）\n")
+	fmt.Printf("  GET  /api/v1/hosts/file - 获取已保存的hosts文件\n")
+	fmt.Printf("  GET  /api/v1/certs      - 各域名选中IP的证书状态\n")
+	fmt.Printf("  GET  /health            - 健康检查\n")
+	fmt.Printf("  GET  /metrics           - Prometheus指标\n")
 	fmt.Printf("定时任务: 启动时立即检测，之后每3小时检测一次\n")
 	fmt.Printf("\n服务启动中...\n")
 
-	if err := http.ListenAndServe(":"+*port, nil); err != nil {
+	if err := service.Start(*port); err != nil {
 		log.Fatalf("启动服务失败: %v", err)
 	}
 }
@@ -0,0 +1,80 @@
+package hosts
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// CertPort 证书校验固定探测443端口，和TLSProber保持一致
+	CertPort = 443
+	// DefaultCertMinDaysValid 证书剩余有效期低于这个天数就视为即将过期，拒绝该候选IP
+	DefaultCertMinDaysValid = 14
+)
+
+// CertInfo 记录一次TLS证书校验的结果
+type CertInfo struct {
+	Domain   string    `json:"domain"`
+	IP       string    `json:"ip"`
+	Valid    bool      `json:"valid"`
+	NotAfter time.Time `json:"not_after"`
+	Issuer   string    `json:"issuer"`
+	SANs     []string  `json:"sans"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// validateCert 以domain作为SNI对ip:443发起一次TLS握手，校验证书链是否覆盖该域名、
+// 且剩余有效期不少于minDaysValid天。握手本身跳过自动校验（InsecureSkipVerify），
+// 改为握手成功后用state.PeerCertificates手动校验证书链与域名，这样"证书不覆盖该IP"
+// 这类场景会表现为CertInfo.Valid=false而不是握手失败，不会被误判成连接问题而放行。
+// 连接本身失败（超时、拒绝等）仍返回error；连接成功但证书不合格时返回的
+// CertInfo.Valid为false，Error说明原因
+func validateCert(ip, domain string, minDaysValid int) (*CertInfo, error) {
+	dialer := &net.Dialer{Timeout: HTTPTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, strconv.Itoa(CertPort)), &tls.Config{
+		ServerName:         domain,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("未获取到证书链")
+	}
+	leaf := state.PeerCertificates[0]
+
+	info := &CertInfo{
+		Domain:   domain,
+		IP:       ip,
+		NotAfter: leaf.NotAfter,
+		Issuer:   leaf.Issuer.CommonName,
+		SANs:     leaf.DNSNames,
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       domain,
+		Intermediates: intermediates,
+	}); err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+
+	if time.Until(leaf.NotAfter) < time.Duration(minDaysValid)*24*time.Hour {
+		info.Error = fmt.Sprintf("证书将于%s过期，剩余有效期不足%d天", leaf.NotAfter.Format("2006-01-02"), minDaysValid)
+		return info, nil
+	}
+
+	info.Valid = true
+	return info, nil
+}
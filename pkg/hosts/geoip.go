@@ -0,0 +1,139 @@
+package hosts
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lionsoul2014/ip2region/binding/golang/xdb"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoInfo 描述一个IP的地理位置和归属信息
+type GeoInfo struct {
+	Country string  `json:"country"`
+	Region  string  `json:"region,omitempty"`
+	ISP     string  `json:"isp,omitempty"`
+	Lat     float64 `json:"lat,omitempty"`
+	Lon     float64 `json:"lon,omitempty"`
+}
+
+// GeoIPProvider 是GeoIP数据源的统一接口，MaxMind GeoLite2和ip2region都实现它，
+// selectBestIP据此对候选IP打分，不关心背后具体用的是哪种数据库
+type GeoIPProvider interface {
+	Lookup(ip string) (*GeoInfo, error)
+	Close() error
+}
+
+// maxmindProvider 基于MaxMind GeoLite2 City mmdb数据库，覆盖全球IP
+type maxmindProvider struct {
+	reader *geoip2.Reader
+}
+
+// NewMaxMindProvider 打开一个MaxMind GeoLite2 mmdb文件
+func NewMaxMindProvider(path string) (GeoIPProvider, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &maxmindProvider{reader: reader}, nil
+}
+
+func (p *maxmindProvider) Lookup(ip string) (*GeoInfo, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("无效IP: %s", ip)
+	}
+
+	city, err := p.reader.City(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GeoInfo{
+		Country: city.Country.IsoCode,
+		Lat:     city.Location.Latitude,
+		Lon:     city.Location.Longitude,
+	}
+	if len(city.Subdivisions) > 0 {
+		info.Region = city.Subdivisions[0].IsoCode
+	}
+
+	return info, nil
+}
+
+func (p *maxmindProvider) Close() error {
+	return p.reader.Close()
+}
+
+// ip2regionProvider 基于ip2region xdb数据库，对国内IP的省市/运营商定位比MaxMind更精确
+type ip2regionProvider struct {
+	searcher *xdb.Searcher
+}
+
+// NewIP2RegionProvider 以纯文件方式打开一个ip2region xdb文件（不预先载入内存，每次查询读文件），数据库固定为IPv4
+func NewIP2RegionProvider(path string) (GeoIPProvider, error) {
+	searcher, err := xdb.NewWithFileOnly(xdb.IPv4, path)
+	if err != nil {
+		return nil, err
+	}
+	return &ip2regionProvider{searcher: searcher}, nil
+}
+
+func (p *ip2regionProvider) Lookup(ip string) (*GeoInfo, error) {
+	region, err := p.searcher.Search(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	// ip2region返回格式固定为 国家|区域|省份|城市|ISP
+	parts := strings.Split(region, "|")
+	info := &GeoInfo{}
+	if len(parts) > 0 {
+		info.Country = normalizeIP2RegionCountry(parts[0])
+	}
+	if len(parts) > 2 {
+		info.Region = parts[2]
+	}
+	if len(parts) > 4 {
+		info.ISP = parts[4]
+	}
+
+	return info, nil
+}
+
+// ip2regionCountryToISO把ip2region本地化的国家名归一化为ISO 3166-1 alpha-2代码，
+// 使applyGeoScore/WithPreferCountries在ip2region和MaxMind两种Provider下能用同一套
+// 国家代码（如 "CN"、"US"）比较，不必因为切换Provider而改写PreferCountries的配置。
+// 未收录在表中的国家名原样返回，调用方应按需扩充此表
+var ip2regionCountryToISO = map[string]string{
+	"中国":   "CN",
+	"美国":   "US",
+	"日本":   "JP",
+	"韩国":   "KR",
+	"新加坡":  "SG",
+	"中国香港": "HK",
+	"中国澳门": "MO",
+	"中国台湾": "TW",
+	"英国":   "GB",
+	"德国":   "DE",
+	"法国":   "FR",
+	"俄罗斯":  "RU",
+	"加拿大":  "CA",
+	"澳大利亚": "AU",
+	"印度":   "IN",
+}
+
+// normalizeIP2RegionCountry把ip2region的国家字段转换为ISO代码，无法识别（含"0"这个
+// ip2region用来表示字段缺失的占位符）时原样返回
+func normalizeIP2RegionCountry(country string) string {
+	if iso, ok := ip2regionCountryToISO[country]; ok {
+		return iso
+	}
+	return country
+}
+
+func (p *ip2regionProvider) Close() error {
+	p.searcher.Close()
+	return nil
+}
@@ -2,6 +2,7 @@ package hosts
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -16,6 +17,10 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/go-ping/ping"
 	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"hosts-server/pkg/metrics"
 )
 
 const (
@@ -39,24 +44,174 @@ var (
 
 // HostResult 存储每个域名的检测结果
 type HostResult struct {
-	Domain string  `json:"domain"`
-	IP     string  `json:"ip"`
-	Ping   float64 `json:"ping"`
-	Error  string  `json:"error,omitempty"`
+	Domain       string    `json:"domain"`
+	IP           string    `json:"ip"`
+	Ping         float64   `json:"ping"`
+	ProbeMethod  string    `json:"probe_method,omitempty"`
+	Cert         *CertInfo `json:"cert,omitempty"`
+	Geo          *GeoInfo  `json:"geo,omitempty"`
+	IPv6         string    `json:"ipv6,omitempty"`
+	Ping6        float64   `json:"ping6,omitempty"`
+	ProbeMethod6 string    `json:"probe_method6,omitempty"`
+	Cert6        *CertInfo `json:"cert6,omitempty"`
+	Geo6         *GeoInfo  `json:"geo6,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Mode 控制HostsDetector解析IPv4/IPv6的策略
+type Mode int
+
+const (
+	// IPv4Only 仅解析并写入IPv4地址（默认行为，兼容历史配置）
+	IPv4Only Mode = iota
+	// IPv6Only 仅解析并写入IPv6（AAAA）地址
+	IPv6Only
+	// DualStack 同时解析IPv4和IPv6，hosts文件中为每个域名各写一行
+	DualStack
+	// PreferFastest 同时解析IPv4和IPv6，按ping延迟择优写入其中一条
+	PreferFastest
+)
+
+// ParseMode 将字符串解析为Mode，支持 ipv4、ipv6、dual、fastest（不区分大小写），
+// 空字符串按ipv4处理，供命令行参数和调度配置复用同一套取值
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "ipv4":
+		return IPv4Only, nil
+	case "ipv6":
+		return IPv6Only, nil
+	case "dual", "dualstack":
+		return DualStack, nil
+	case "fastest", "preferfastest":
+		return PreferFastest, nil
+	default:
+		return IPv4Only, fmt.Errorf("不支持的mode: %s（仅支持ipv4/ipv6/dual/fastest）", s)
+	}
 }
 
 // HostsDetector hosts检测器
 type HostsDetector struct {
-	domainFile string
-	outputFile string
+	domainFile       string
+	outputFile       string
+	resolvers        []Resolver
+	mode             Mode
+	probers          []Prober
+	proberWeights    map[string]float64
+	certMinDaysValid int
+	geoIPPath        string
+	ip2regionPath    string
+	preferCountries  []string
+	geoProvider      GeoIPProvider
+	geoOnce          sync.Once
+}
+
+// Option 用于配置HostsDetector的可选项
+type Option func(*HostsDetector)
+
+// WithResolvers 指定DNS解析器列表，替换默认的DNSServers按UDP解析的行为，
+// 可以混用udp://、tls://、https://三种协议的解析器
+func WithResolvers(resolvers []Resolver) Option {
+	return func(hd *HostsDetector) {
+		hd.resolvers = resolvers
+	}
+}
+
+// WithMode 指定IPv4/IPv6解析策略，默认为IPv4Only
+func WithMode(mode Mode) Option {
+	return func(hd *HostsDetector) {
+		hd.mode = mode
+	}
+}
+
+// WithProbers 指定延迟探测链，替换默认的TCP/TLS/HTTP探测组合
+func WithProbers(probers []Prober) Option {
+	return func(hd *HostsDetector) {
+		hd.probers = probers
+	}
+}
+
+// WithProberWeights 指定各探测方式在评分时的权重，key为Prober.Name()
+func WithProberWeights(weights map[string]float64) Option {
+	return func(hd *HostsDetector) {
+		hd.proberWeights = weights
+	}
+}
+
+// WithCertMinDaysValid 指定候选IP证书最少需要剩余多少天有效期，默认DefaultCertMinDaysValid
+func WithCertMinDaysValid(days int) Option {
+	return func(hd *HostsDetector) {
+		hd.certMinDaysValid = days
+	}
+}
+
+// WithGeoIPPath 指定MaxMind GeoLite2 mmdb数据库路径，用于为候选IP标注国家/地区和经纬度
+func WithGeoIPPath(path string) Option {
+	return func(hd *HostsDetector) {
+		hd.geoIPPath = path
+	}
+}
+
+// WithIP2RegionPath 指定ip2region xdb数据库路径；同时配置了GeoIPPath时优先使用ip2region，
+// 因为它对国内IP的省市/运营商定位更精确
+func WithIP2RegionPath(path string) Option {
+	return func(hd *HostsDetector) {
+		hd.ip2regionPath = path
+	}
+}
+
+// WithPreferCountries 指定优先选择的国家/地区代码（如 HK、JP、US）。
+// 当多个候选IP的探测延迟接近时，落在偏好地区内的IP会被优先选中，
+// 这对身处受限网络、只有部分地理路径可达的用户很有用
+func WithPreferCountries(countries []string) Option {
+	return func(hd *HostsDetector) {
+		hd.preferCountries = countries
+	}
 }
 
 // NewHostsDetector 创建新的hosts检测器
-func NewHostsDetector(domainFile, outputFile string) *HostsDetector {
-	return &HostsDetector{
-		domainFile: domainFile,
-		outputFile: outputFile,
+func NewHostsDetector(domainFile, outputFile string, opts ...Option) *HostsDetector {
+	hd := &HostsDetector{
+		domainFile:       domainFile,
+		outputFile:       outputFile,
+		resolvers:        defaultResolvers(),
+		mode:             IPv4Only,
+		probers:          defaultProbers(),
+		proberWeights:    defaultProberWeights(),
+		certMinDaysValid: DefaultCertMinDaysValid,
 	}
+
+	for _, opt := range opts {
+		opt(hd)
+	}
+
+	return hd
+}
+
+// geo 惰性初始化并返回配置的GeoIP数据源，没有配置任何数据库路径时返回nil，
+// 此时selectBestIP跳过地理位置打分，完全依赖探测延迟
+func (hd *HostsDetector) geo() GeoIPProvider {
+	hd.geoOnce.Do(func() {
+		var provider GeoIPProvider
+		var err error
+
+		switch {
+		case hd.ip2regionPath != "":
+			provider, err = NewIP2RegionProvider(hd.ip2regionPath)
+		case hd.geoIPPath != "":
+			provider, err = NewMaxMindProvider(hd.geoIPPath)
+		default:
+			return
+		}
+
+		if err != nil {
+			log.Printf("加载GeoIP数据库失败: %v", err)
+			return
+		}
+
+		hd.geoProvider = provider
+	})
+
+	return hd.geoProvider
 }
 
 // readDomainFile 读取域名文件
@@ -92,11 +247,14 @@ func pingCached(ip string) float64 {
 	PingMutex.RLock()
 	if result, exists := PingCache[ip]; exists {
 		PingMutex.RUnlock()
+		metrics.RecordPingCache(true)
 		return result
 	}
 	PingMutex.RUnlock()
+	metrics.RecordPingCache(false)
 
-	// 执行ping测试
+	// 执行ping测试。go-ping会根据ip是v4还是v6字面量自动选择ICMP/ICMPv6协议，
+	// 所以这里无需区分地址族即可支持AAAA候选
 	pinger, err := ping.NewPinger(ip)
 	if err != nil {
 		log.Printf("创建pinger失败 %s: %v", ip, err)
@@ -132,44 +290,153 @@ func pingCached(ip string) float64 {
 	PingCache[ip] = median
 	PingMutex.Unlock()
 
+	// RTT histogram由probeCached统一记录（ICMPProber也经由它调用），这里不再重复上报
 	return median
 }
 
-// selectBestIP 从IP列表中选择最佳IP
-func selectBestIP(ipList []string) string {
+// ipCandidate 是rankIPs对一个候选IP打分后的结果。Score叠加了applyGeoScore的地理位置
+// 偏好调整，只用于候选间的排序/取舍；RawScore是未经地理加权的真实探测延迟（毫秒），
+// 对外上报（HostResult.Ping/Ping6）用这个，避免地理加权把真实延迟篡改成误导性的数值
+type ipCandidate struct {
+	IP       string
+	Score    float64
+	RawScore float64
+	Method   string
+	Geo      *GeoInfo
+}
+
+// rankIPs 对候选IP分别跑一遍探测链，按权重合成得分，再按PreferCountries对地理位置加权后
+// 由低到高排序
+func (hd *HostsDetector) rankIPs(domain string, ipList []string) []ipCandidate {
 	if len(ipList) == 0 {
-		return ""
+		return nil
 	}
 
-	type ipPing struct {
-		IP   string
-		Ping float64
+	probers := hd.probers
+	if len(probers) == 0 {
+		probers = defaultProbers()
+	}
+	weights := hd.proberWeights
+	if len(weights) == 0 {
+		weights = defaultProberWeights()
 	}
+	geoProvider := hd.geo()
 
-	var results []ipPing
+	var candidates []ipCandidate
 	for _, ip := range ipList {
-		pingTime := pingCached(ip)
-		results = append(results, ipPing{IP: ip, Ping: pingTime})
+		var weightedSum, weightTotal float64
+		var bestMethod string
+		bestValue := float64(PingTimeoutSec * 1000)
+		probed := false
+
+		for _, p := range probers {
+			v, ok := probeCached(p, ip, domain)
+			if !ok {
+				continue
+			}
+
+			probed = true
+			w := weights[p.Name()]
+			if w == 0 {
+				w = 1
+			}
+			weightedSum += v * w
+			weightTotal += w
+
+			if bestMethod == "" || v < bestValue {
+				bestValue = v
+				bestMethod = p.Name()
+			}
+		}
+
+		var score float64
+		var method string
+		if !probed {
+			// TCP/TLS/HTTP探测均不可用（例如端口被封锁），回退到ICMP
+			icmp := &ICMPProber{}
+			v, _ := probeCached(icmp, ip, domain)
+			score, method = v, icmp.Name()
+		} else {
+			score, method = weightedSum/weightTotal, bestMethod
+		}
+
+		var geo *GeoInfo
+		if geoProvider != nil {
+			if g, err := geoProvider.Lookup(ip); err == nil {
+				geo = g
+			}
+		}
+
+		candidates = append(candidates, ipCandidate{IP: ip, Score: hd.applyGeoScore(score, geo), RawScore: score, Method: method, Geo: geo})
 	}
 
-	// 按ping时间排序
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Ping < results[j].Ping
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Score < candidates[j].Score
 	})
 
-	bestIP := results[0].IP
-	fmt.Printf("IP候选: %v, 选择: %s (%.2f ms)\n", ipList, bestIP, results[0].Ping)
+	return candidates
+}
+
+// applyGeoScore 按PreferCountries调整延迟得分：候选IP所在国家命中偏好列表则降低得分
+// （优先选中），否则略微调高得分（降低优先级），用于在多个IP延迟接近时打破平局
+func (hd *HostsDetector) applyGeoScore(score float64, geo *GeoInfo) float64 {
+	if geo == nil || len(hd.preferCountries) == 0 {
+		return score
+	}
+
+	for _, country := range hd.preferCountries {
+		if strings.EqualFold(country, geo.Country) {
+			return score * 0.7
+		}
+	}
 
-	return bestIP
+	return score * 1.3
+}
+
+// selectBestIP 对候选IP分别跑一遍探测链，按权重合成得分、叠加地理位置偏好后选出最佳IP
+// 并用TLS证书把关，返回选中的IP、促成这次选择的主要探测方式、它真实测得的耗时（毫秒，
+// 未经地理位置加权）、地理位置信息，以及证书校验结果（无法建立TLS连接或所有候选证书都不合格时为nil）。排名
+// 靠前但证书不合格的候选会被跳过，改选下一个
+func (hd *HostsDetector) selectBestIP(domain string, ipList []string) (string, string, float64, *GeoInfo, *CertInfo) {
+	candidates := hd.rankIPs(domain, ipList)
+	if len(candidates) == 0 {
+		return "", "", 0, nil, nil
+	}
+
+	for _, c := range candidates {
+		cert, err := validateCert(c.IP, domain, hd.certMinDaysValid)
+		if err != nil {
+			// 连接失败等非证书问题，大概率TLS探测也失败过，仍然接受这个候选
+			log.Printf("证书校验连接失败 %s (%s): %v", domain, c.IP, err)
+			fmt.Printf("IP候选: %v, 选择: %s (%.2f ms, %s)\n", ipList, c.IP, c.RawScore, c.Method)
+			return c.IP, c.Method, c.RawScore, c.Geo, nil
+		}
+		if !cert.Valid {
+			log.Printf("候选IP %s 证书校验未通过，跳过: %s", c.IP, cert.Error)
+			continue
+		}
+
+		fmt.Printf("IP候选: %v, 选择: %s (%.2f ms, %s)\n", ipList, c.IP, c.RawScore, c.Method)
+		return c.IP, c.Method, c.RawScore, c.Geo, cert
+	}
+
+	// 所有候选证书都不合格，退回排名最靠前（得分最低）的那个，避免整个域名无IP可用
+	best := candidates[0]
+	log.Printf("%s 所有候选IP证书校验均未通过，退回排名最靠前的 %s", domain, best.IP)
+	return best.IP, best.Method, best.RawScore, best.Geo, nil
 }
 
 // getIPFromWeb 从ipaddress.com获取IP地址
-func getIPFromWeb(domain string) ([]string, error) {
+func getIPFromWeb(ctx context.Context, domain string) ([]string, error) {
+	_, span := metrics.Tracer.Start(ctx, "web_scrape", trace.WithAttributes(attribute.String("domain", domain)))
+	defer span.End()
+
 	url := fmt.Sprintf("https://sites.ipaddress.com/%s", domain)
 
 	client := &http.Client{Timeout: HTTPTimeout}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
+		metrics.RecordWebScrape(false)
 		return nil, err
 	}
 
@@ -177,12 +444,14 @@ func getIPFromWeb(domain string) ([]string, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
+		metrics.RecordWebScrape(false)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
+		metrics.RecordWebScrape(false)
 		return nil, err
 	}
 
@@ -191,40 +460,53 @@ func getIPFromWeb(domain string) ([]string, error) {
 	ipRegex := regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`)
 	ipList := ipRegex.FindAllString(text, -1)
 
+	metrics.RecordWebScrape(true)
 	return ipList, nil
 }
 
-// getIPFromDNS 通过DNS查询获取IP地址
-func getIPFromDNS(domain string) ([]string, error) {
-	var allIPs []string
+// getIPFromDNS 并发查询所有已配置的解析器（UDP/DoT/DoH），合并它们各自拿到的IP，
+// 而不是像过去那样查到第一个有结果的服务器就停止——这样既能抵抗单一解析器被污染或屏蔽，
+// 也能扩大selectBestIP可选择的候选池
+func (hd *HostsDetector) getIPFromDNS(ctx context.Context, domain string, qtype uint16) ([]string, error) {
+	_, span := metrics.Tracer.Start(ctx, "dns_resolve", trace.WithAttributes(attribute.String("domain", domain)))
+	defer span.End()
 
-	for _, server := range DNSServers {
-		c := dns.Client{Timeout: 3 * time.Second}
-		m := dns.Msg{}
-		m.SetQuestion(dns.Fqdn(domain), dns.TypeA)
+	resolvers := hd.resolvers
+	if len(resolvers) == 0 {
+		resolvers = defaultResolvers()
+	}
 
-		r, _, err := c.Exchange(&m, server)
-		if err != nil {
-			continue
-		}
+	var mu sync.Mutex
+	var allIPs []string
 
-		for _, ans := range r.Answer {
-			if a, ok := ans.(*dns.A); ok {
-				allIPs = append(allIPs, a.A.String())
+	var wg sync.WaitGroup
+	wg.Add(len(resolvers))
+	for _, r := range resolvers {
+		go func(r Resolver) {
+			defer wg.Done()
+			ips, err := r.Resolve(domain, qtype)
+			if err != nil {
+				log.Printf("DNS解析失败 %s (%s): %v", domain, r.String(), err)
+				metrics.RecordDNSQuery(r.String(), false)
+				return
 			}
-		}
-
-		// 如果已经获得了结果，就不需要继续查询其他DNS服务器
-		if len(allIPs) > 0 {
-			break
-		}
+			metrics.RecordDNSQuery(r.String(), true)
+			mu.Lock()
+			allIPs = append(allIPs, ips...)
+			mu.Unlock()
+		}(r)
 	}
+	wg.Wait()
 
 	return allIPs, nil
 }
 
-// getBestIP 获取域名的最佳IP地址
-func getBestIP(domain string) (string, error) {
+// getBestIP 获取域名的最佳IPv4地址，返回IP、促成选择的探测方式、它的耗时（毫秒）、
+// 地理位置信息以及证书校验结果
+func (hd *HostsDetector) getBestIP(ctx context.Context, domain string) (string, string, float64, *GeoInfo, *CertInfo, error) {
+	ctx, span := metrics.Tracer.Start(ctx, "getBestIP", trace.WithAttributes(attribute.String("domain", domain)))
+	defer span.End()
+
 	var webIPs, dnsIPs []string
 
 	// 并发获取Web和DNS结果
@@ -233,20 +515,59 @@ func getBestIP(domain string) (string, error) {
 
 	go func() {
 		defer wg.Done()
-		webIPs, _ = getIPFromWeb(domain)
+		webIPs, _ = getIPFromWeb(ctx, domain)
 	}()
 
 	go func() {
 		defer wg.Done()
-		dnsIPs, _ = getIPFromDNS(domain)
+		dnsIPs, _ = hd.getIPFromDNS(ctx, domain, dns.TypeA)
 	}()
 
 	wg.Wait()
 
-	// 合并并去重IP列表
+	ipList := filterValidIPs(append(webIPs, dnsIPs...))
+	if len(ipList) == 0 {
+		return "", "", 0, nil, nil, fmt.Errorf("未找到有效IP地址")
+	}
+
+	fmt.Printf("%s: %v\n", domain, ipList)
+
+	bestIP, method, ping, geo, cert := hd.selectBestIPTraced(ctx, domain, ipList)
+	return bestIP, method, ping, geo, cert, nil
+}
+
+// getBestIPv6 获取域名的最佳IPv6地址。ipaddress.com的网页抓取只暴露IPv4字面量，
+// 因此AAAA候选完全依赖DNS解析器
+func (hd *HostsDetector) getBestIPv6(ctx context.Context, domain string) (string, string, float64, *GeoInfo, *CertInfo, error) {
+	ctx, span := metrics.Tracer.Start(ctx, "getBestIPv6", trace.WithAttributes(attribute.String("domain", domain)))
+	defer span.End()
+
+	dnsIPs, _ := hd.getIPFromDNS(ctx, domain, dns.TypeAAAA)
+
+	ipList := filterValidIPs(dnsIPs)
+	if len(ipList) == 0 {
+		return "", "", 0, nil, nil, fmt.Errorf("未找到有效IPv6地址")
+	}
+
+	fmt.Printf("%s (AAAA): %v\n", domain, ipList)
+
+	bestIP, method, ping, geo, cert := hd.selectBestIPTraced(ctx, domain, ipList)
+	return bestIP, method, ping, geo, cert, nil
+}
+
+// selectBestIPTraced 包一层ping探测+证书校验阶段的span后再调用selectBestIP，
+// 方便在链路追踪中区分DNS/网页抓取和实际选优耗时多少
+func (hd *HostsDetector) selectBestIPTraced(ctx context.Context, domain string, ipList []string) (string, string, float64, *GeoInfo, *CertInfo) {
+	_, span := metrics.Tracer.Start(ctx, "select_best_ip", trace.WithAttributes(attribute.String("domain", domain)))
+	defer span.End()
+
+	return hd.selectBestIP(domain, ipList)
+}
+
+// filterValidIPs 去重、丢弃DiscardList中的地址，并过滤掉无法解析的字面量
+func filterValidIPs(ips []string) []string {
 	ipSet := make(map[string]bool)
-	for _, ip := range append(webIPs, dnsIPs...) {
-		// 过滤掉不需要的IP
+	for _, ip := range ips {
 		skip := false
 		for _, discardIP := range DiscardList {
 			if ip == discardIP {
@@ -259,38 +580,120 @@ func getBestIP(domain string) (string, error) {
 		}
 	}
 
-	if len(ipSet) == 0 {
-		return "", fmt.Errorf("未找到有效IP地址")
-	}
-
-	// 转换为切片并排序
 	var ipList []string
 	for ip := range ipSet {
 		ipList = append(ipList, ip)
 	}
 	sort.Strings(ipList)
 
-	fmt.Printf("%s: %v\n", domain, ipList)
-
-	bestIP := selectBestIP(ipList)
-	return bestIP, nil
+	return ipList
 }
 
-// processHost 处理单个域名
-func processHost(domain string, resultChan chan<- HostResult) {
+// processHost 处理单个域名，根据hd.mode决定解析IPv4、IPv6还是两者都解析
+func (hd *HostsDetector) processHost(domain string, resultChan chan<- HostResult) {
 	fmt.Printf("开始处理域名: %s\n", domain)
 
-	ip, err := getBestIP(domain)
+	start := time.Now()
+	ctx, span := metrics.Tracer.Start(context.Background(), "processHost", trace.WithAttributes(attribute.String("domain", domain)))
+	defer span.End()
+
 	result := HostResult{Domain: domain}
 
-	if err != nil {
-		result.Error = err.Error()
-		result.IP = "# IP Address Not Found"
-		fmt.Printf("%s: IP未找到 - %v\n", domain, err)
-	} else {
-		result.IP = ip
-		result.Ping = pingCached(ip)
-		fmt.Printf("%s: 选择IP %s (%.2f ms)\n", domain, ip, result.Ping)
+	needV4 := hd.mode != IPv6Only
+	needV6 := hd.mode != IPv4Only
+
+	var ip4, method4, ip6, method6 string
+	var ping4, ping6 float64
+	var geo4, geo6 *GeoInfo
+	var cert4, cert6 *CertInfo
+	var err4, err6 error
+
+	var wg sync.WaitGroup
+	if needV4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip4, method4, ping4, geo4, cert4, err4 = hd.getBestIP(ctx, domain)
+		}()
+	}
+	if needV6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip6, method6, ping6, geo6, cert6, err6 = hd.getBestIPv6(ctx, domain)
+		}()
+	}
+	wg.Wait()
+
+	switch hd.mode {
+	case IPv6Only:
+		if err6 != nil {
+			result.Error = err6.Error()
+			result.IPv6 = "# IP Address Not Found"
+			fmt.Printf("%s: IPv6未找到 - %v\n", domain, err6)
+		} else {
+			result.IPv6 = ip6
+			result.Ping6 = ping6
+			result.ProbeMethod6 = method6
+			result.Cert6 = cert6
+			result.Geo6 = geo6
+			fmt.Printf("%s: 选择IPv6 %s (%.2f ms, %s)\n", domain, ip6, ping6, method6)
+		}
+
+	case PreferFastest:
+		switch {
+		case err4 != nil && err6 != nil:
+			result.Error = "未找到有效IP地址"
+			result.IP = "# IP Address Not Found"
+		case err6 == nil && (err4 != nil || ping6 < ping4):
+			result.IPv6 = ip6
+			result.Ping6 = ping6
+			result.ProbeMethod6 = method6
+			result.Cert6 = cert6
+			result.Geo6 = geo6
+			fmt.Printf("%s: 选择IPv6 %s (%.2f ms, %s)\n", domain, ip6, ping6, method6)
+		default:
+			result.IP = ip4
+			result.Ping = ping4
+			result.ProbeMethod = method4
+			result.Cert = cert4
+			result.Geo = geo4
+			fmt.Printf("%s: 选择IPv4 %s (%.2f ms, %s)\n", domain, ip4, ping4, method4)
+		}
+
+	default: // IPv4Only、DualStack
+		if err4 != nil {
+			result.Error = err4.Error()
+			result.IP = "# IP Address Not Found"
+			fmt.Printf("%s: IP未找到 - %v\n", domain, err4)
+		} else {
+			result.IP = ip4
+			result.Ping = ping4
+			result.ProbeMethod = method4
+			result.Cert = cert4
+			result.Geo = geo4
+			fmt.Printf("%s: 选择IP %s (%.2f ms, %s)\n", domain, ip4, ping4, method4)
+		}
+
+		if hd.mode == DualStack {
+			if err6 != nil {
+				fmt.Printf("%s: IPv6未找到 - %v\n", domain, err6)
+			} else {
+				result.IPv6 = ip6
+				result.Ping6 = ping6
+				result.ProbeMethod6 = method6
+				result.Cert6 = cert6
+				result.Geo6 = geo6
+				fmt.Printf("%s: 选择IPv6 %s (%.2f ms, %s)\n", domain, ip6, ping6, method6)
+			}
+		}
+	}
+
+	metrics.RecordDetection(domain, time.Since(start), result.Error)
+	if result.IP != "" && result.IP != "# IP Address Not Found" {
+		metrics.SetChosenIP(domain, result.IP)
+	} else if result.IPv6 != "" && result.IPv6 != "# IP Address Not Found" {
+		metrics.SetChosenIP(domain, result.IPv6)
 	}
 
 	resultChan <- result
@@ -316,17 +719,40 @@ func (hd *HostsDetector) WriteHostsFile(results []HostResult) error {
 
 	// 写入hosts条目
 	for _, result := range results {
-		line := fmt.Sprintf("%-30s %s", result.IP, result.Domain)
+		for _, line := range hostsLines(result) {
+			fmt.Fprintf(writer, "%s\n", line)
+		}
+	}
+
+	return nil
+}
+
+// hostsLines 根据一个域名的检测结果生成hosts文件中的行，
+// DualStack模式下同一域名可能产出IPv4、IPv6两行；PreferFastest只选其一，最多一行
+func hostsLines(result HostResult) []string {
+	var lines []string
 
-		// 添加超时标记
+	if result.IP != "" {
+		line := fmt.Sprintf("%-30s %s", result.IP, result.Domain)
 		if result.Ping >= float64(PingTimeoutSec*1000) {
 			line += "  # Timeout"
+		} else if result.ProbeMethod != "" {
+			line += fmt.Sprintf("  # %s", result.ProbeMethod)
 		}
+		lines = append(lines, line)
+	}
 
-		fmt.Fprintf(writer, "%s\n", line)
+	if result.IPv6 != "" {
+		line := fmt.Sprintf("%-30s %s", result.IPv6, result.Domain)
+		if result.Ping6 >= float64(PingTimeoutSec*1000) {
+			line += "  # Timeout"
+		} else if result.ProbeMethod6 != "" {
+			line += fmt.Sprintf("  # %s", result.ProbeMethod6)
+		}
+		lines = append(lines, line)
 	}
 
-	return nil
+	return lines
 }
 
 // GenerateHostsContent 生成hosts内容字符串（公开方法）
@@ -342,19 +768,22 @@ func (hd *HostsDetector) GenerateHostsContent(results []HostResult) string {
 
 	// 写入hosts条目
 	for _, result := range results {
-		line := fmt.Sprintf("%-30s %s", result.IP, result.Domain)
-
-		// 添加超时标记
-		if result.Ping >= float64(PingTimeoutSec*1000) {
-			line += "  # Timeout"
+		for _, line := range hostsLines(result) {
+			content.WriteString(line + "\n")
 		}
-
-		content.WriteString(line + "\n")
 	}
 
 	return content.String()
 }
 
+// DetectOne 检测单个域名并返回结果，不依赖domainFile。
+// 供需要按域名粒度单独触发检测的调用方（如按域名各自节奏运行的调度器）使用
+func (hd *HostsDetector) DetectOne(domain string) HostResult {
+	resultChan := make(chan HostResult, 1)
+	hd.processHost(domain, resultChan)
+	return <-resultChan
+}
+
 // DetectHosts 检测hosts并返回结果
 func (hd *HostsDetector) DetectHosts() ([]HostResult, error) {
 	// 读取域名列表
@@ -381,7 +810,7 @@ func (hd *HostsDetector) DetectHosts() ([]HostResult, error) {
 			defer wg.Done()
 			semaphore <- struct{}{} // 获取信号量
 			fmt.Printf("开始处理 %d/%d: %s\n", index+1, len(domains), d)
-			processHost(d, resultChan)
+			hd.processHost(d, resultChan)
 			<-semaphore // 释放信号量
 		}(i, domain)
 	}
@@ -452,3 +881,31 @@ func (hd *HostsDetector) GetHostsContent() (string, error) {
 
 	return hd.GenerateHostsContent(results), nil
 }
+
+// GetCertStatus 对所有域名做一次完整检测并汇总每个域名当前选中IP的证书状态，
+// 运维可以据此发现即将过期或SAN不再覆盖域名的候选IP。/api/v1/certs不调用它，
+// 而是对HostsService的结果快照调用CertsFromResults
+func (hd *HostsDetector) GetCertStatus() ([]*CertInfo, error) {
+	results, err := hd.DetectHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	return CertsFromResults(results), nil
+}
+
+// CertsFromResults 从一批已有的检测结果里汇总每个域名选中IP的证书状态，不触发新的检测，
+// 供已经拿到结果快照的调用方（如读取调度器Results()的HostsService）复用
+func CertsFromResults(results []HostResult) []*CertInfo {
+	var certs []*CertInfo
+	for _, result := range results {
+		if result.Cert != nil {
+			certs = append(certs, result.Cert)
+		}
+		if result.Cert6 != nil {
+			certs = append(certs, result.Cert6)
+		}
+	}
+
+	return certs
+}
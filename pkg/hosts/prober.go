@@ -0,0 +1,175 @@
+package hosts
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"hosts-server/pkg/metrics"
+)
+
+var (
+	ProbeCache = make(map[string]float64)
+	ProbeMutex = sync.RWMutex{}
+)
+
+// Prober 定义一种延迟探测方式。ICMP经常在云服务商的边缘节点上被限速或屏蔽，
+// 所以除了ping之外还提供TCP/TLS/HTTP三种更贴近真实访问路径的探测手段
+type Prober interface {
+	// Probe 对ip执行一次探测，domain用于需要SNI/Host的场景，返回耗时（毫秒）
+	Probe(ip, domain string) (float64, error)
+	// Name 探测方式标识，用作缓存key的一部分以及结果标注，如 "tcp:443"
+	Name() string
+}
+
+// TCPProber 通过TCP三次握手耗时衡量延迟
+type TCPProber struct {
+	Port int
+}
+
+func (p *TCPProber) Probe(ip, domain string) (float64, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(p.Port)), HTTPTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return float64(time.Since(start).Nanoseconds()) / 1e6, nil
+}
+
+func (p *TCPProber) Name() string {
+	return fmt.Sprintf("tcp:%d", p.Port)
+}
+
+// TLSProber 通过TLS握手耗时衡量延迟，SNI设置为目标域名，
+// 能顺带发现候选IP是否仍在该域名的证书路由范围内
+type TLSProber struct {
+	Port int
+}
+
+func (p *TLSProber) Probe(ip, domain string) (float64, error) {
+	dialer := &net.Dialer{Timeout: HTTPTimeout}
+
+	start := time.Now()
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, strconv.Itoa(p.Port)), &tls.Config{ServerName: domain})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	return float64(time.Since(start).Nanoseconds()) / 1e6, nil
+}
+
+func (p *TLSProber) Name() string {
+	return fmt.Sprintf("tls:%d", p.Port)
+}
+
+// HTTPProber 发起一次完整的HTTPS GET请求，以首字节到达时间（TTFB）衡量延迟，
+// 是三种探测里最接近真实用户体验的一种，但耗时也最长
+type HTTPProber struct {
+	Port int
+}
+
+func (p *HTTPProber) Probe(ip, domain string) (float64, error) {
+	addr := net.JoinHostPort(ip, strconv.Itoa(p.Port))
+	dialer := &net.Dialer{Timeout: HTTPTimeout}
+
+	client := &http.Client{
+		Timeout: HTTPTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s/", domain), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	ttfb := float64(time.Since(start).Nanoseconds()) / 1e6
+	io.Copy(io.Discard, resp.Body)
+
+	return ttfb, nil
+}
+
+func (p *HTTPProber) Name() string {
+	return fmt.Sprintf("http:%d", p.Port)
+}
+
+// ICMPProber 回退到传统ICMP ping，仅在TCP/TLS/HTTP探测都不可用时使用
+type ICMPProber struct{}
+
+func (p *ICMPProber) Probe(ip, domain string) (float64, error) {
+	return pingCached(ip), nil
+}
+
+func (p *ICMPProber) Name() string {
+	return "icmp"
+}
+
+// probeCacheKey 缓存key必须包含(domain, ip, port, probe-type)，而不能只用(ip, probe-type)：
+// TLS/HTTP探测的结果依赖domain（SNI/Host头会影响CDN的后端选择和响应耗时），两个域名的
+// 候选池共享同一个边缘IP在CDN场景下很常见（Fastly/Cloudflare/GitHub Pages等），缺了
+// domain会让后探测的域名直接复用前一个域名的缓存RTT，而不是真的用自己的SNI/Host探测一次
+func probeCacheKey(ip, domain string, p Prober) string {
+	return fmt.Sprintf("%s|%s|%s", domain, ip, p.Name())
+}
+
+// probeCached 带缓存地执行一次探测，探测失败返回ok=false
+func probeCached(p Prober, ip, domain string) (float64, bool) {
+	key := probeCacheKey(ip, domain, p)
+
+	ProbeMutex.RLock()
+	if v, exists := ProbeCache[key]; exists {
+		ProbeMutex.RUnlock()
+		return v, true
+	}
+	ProbeMutex.RUnlock()
+
+	v, err := p.Probe(ip, domain)
+	if err != nil {
+		return 0, false
+	}
+
+	ProbeMutex.Lock()
+	ProbeCache[key] = v
+	ProbeMutex.Unlock()
+
+	metrics.ObserveProbeRTT(p.Name(), v)
+	return v, true
+}
+
+// defaultProbers 默认的探测链：TCP连接耗时、TLS握手耗时、HTTP TTFB
+func defaultProbers() []Prober {
+	return []Prober{
+		&TCPProber{Port: 443},
+		&TLSProber{Port: 443},
+		&HTTPProber{Port: 443},
+	}
+}
+
+// defaultProberWeights 默认权重，HTTP探测耗时最久、信号也最贴近真实访问，
+// 但为了不让慢速探测主导评分，权重略低于TCP/TLS
+func defaultProberWeights() map[string]float64 {
+	return map[string]float64{
+		"tcp:443":  1.0,
+		"tls:443":  1.0,
+		"http:443": 0.5,
+	}
+}
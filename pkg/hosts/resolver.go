@@ -0,0 +1,180 @@
+package hosts
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Resolver 是所有DNS解析方式需要实现的统一接口，
+// 让UDP/DoT/DoH等不同传输协议可以被上层以同样的方式调用。
+type Resolver interface {
+	// Resolve 查询指定域名的记录，qtype 为 dns.TypeA 或 dns.TypeAAAA
+	Resolve(domain string, qtype uint16) ([]string, error)
+	// String 返回解析器的描述，用于日志输出
+	String() string
+}
+
+// UDPResolver 基于传统UDP DNS协议的解析器
+type UDPResolver struct {
+	Addr string
+}
+
+func (r *UDPResolver) Resolve(domain string, qtype uint16) ([]string, error) {
+	c := dns.Client{Timeout: 3 * time.Second}
+	m := dns.Msg{}
+	m.SetQuestion(dns.Fqdn(domain), qtype)
+
+	resp, _, err := c.Exchange(&m, r.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractIPs(resp, qtype), nil
+}
+
+func (r *UDPResolver) String() string {
+	return fmt.Sprintf("udp://%s", r.Addr)
+}
+
+// DoTResolver 基于DNS-over-TLS协议的解析器
+type DoTResolver struct {
+	Addr string
+}
+
+func (r *DoTResolver) Resolve(domain string, qtype uint16) ([]string, error) {
+	c := dns.Client{Net: "tcp-tls", Timeout: 3 * time.Second, TLSConfig: &tls.Config{}}
+	m := dns.Msg{}
+	m.SetQuestion(dns.Fqdn(domain), qtype)
+
+	resp, _, err := c.Exchange(&m, r.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractIPs(resp, qtype), nil
+}
+
+func (r *DoTResolver) String() string {
+	return fmt.Sprintf("tls://%s", r.Addr)
+}
+
+// DoHResolver 基于DNS-over-HTTPS协议（RFC 8484 GET wireformat）的解析器
+type DoHResolver struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+func (r *DoHResolver) Resolve(domain string, qtype uint16) ([]string, error) {
+	m := dns.Msg{}
+	m.SetQuestion(dns.Fqdn(domain), qtype)
+	m.Id = 0
+
+	packed, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: HTTPTimeout}
+	}
+
+	query := base64.RawURLEncoding.EncodeToString(packed)
+	req, err := http.NewRequest("GET", r.Endpoint+"?dns="+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := dns.Msg{}
+	if err := reply.Unpack(body); err != nil {
+		return nil, err
+	}
+
+	return extractIPs(&reply, qtype), nil
+}
+
+func (r *DoHResolver) String() string {
+	return r.Endpoint
+}
+
+// extractIPs 从DNS响应中提取A/AAAA记录的IP地址
+func extractIPs(resp *dns.Msg, qtype uint16) []string {
+	if resp == nil {
+		return nil
+	}
+
+	var ips []string
+	for _, ans := range resp.Answer {
+		switch qtype {
+		case dns.TypeA:
+			if a, ok := ans.(*dns.A); ok {
+				ips = append(ips, a.A.String())
+			}
+		case dns.TypeAAAA:
+			if aaaa, ok := ans.(*dns.AAAA); ok {
+				ips = append(ips, aaaa.AAAA.String())
+			}
+		}
+	}
+	return ips
+}
+
+// NewResolver 根据URL风格的地址构造对应协议的Resolver，支持：
+//   udp://1.1.1.1:53        传统UDP DNS（省略端口默认53）
+//   tls://1.1.1.1:853       DNS-over-TLS（省略端口默认853）
+//   https://host/dns-query  DNS-over-HTTPS
+//
+// 为兼容历史配置，没有协议前缀的地址（如 "1.1.1.1:53"）按UDP处理。
+func NewResolver(addr string) (Resolver, error) {
+	switch {
+	case strings.HasPrefix(addr, "https://"):
+		return &DoHResolver{Endpoint: addr}, nil
+	case strings.HasPrefix(addr, "tls://"):
+		host := strings.TrimPrefix(addr, "tls://")
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "853")
+		}
+		return &DoTResolver{Addr: host}, nil
+	case strings.HasPrefix(addr, "udp://"):
+		host := strings.TrimPrefix(addr, "udp://")
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "53")
+		}
+		return &UDPResolver{Addr: host}, nil
+	default:
+		return &UDPResolver{Addr: addr}, nil
+	}
+}
+
+// defaultResolvers 基于DNSServers构造默认的解析器列表
+func defaultResolvers() []Resolver {
+	resolvers := make([]Resolver, 0, len(DNSServers))
+	for _, server := range DNSServers {
+		r, err := NewResolver(server)
+		if err != nil {
+			continue
+		}
+		resolvers = append(resolvers, r)
+	}
+	return resolvers
+}
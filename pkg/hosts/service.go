@@ -6,18 +6,54 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// ResultsProvider 返回当前已知的全部检测结果快照，不触发新的探测。按调度配置运行时
+// 由scheduler.Scheduler.Results提供，这样API读到的是调度器按各域名Interval/
+// ChangeThreshold节奏产出的结果，而不是和调度goroutine各跑一遍DNS/探测/证书、
+// 互相竞争资源的临时全量检测
+type ResultsProvider func() []HostResult
+
 // HostsService hosts服务结构体
 type HostsService struct {
-	detector *HostsDetector
+	detector        *HostsDetector
+	resultsProvider ResultsProvider
 }
 
 // NewHostsService 创建新的hosts服务
-func NewHostsService(domainFile, outputFile string) *HostsService {
+func NewHostsService(domainFile, outputFile string, opts ...Option) *HostsService {
 	return &HostsService{
-		detector: NewHostsDetector(domainFile, outputFile),
+		detector: NewHostsDetector(domainFile, outputFile, opts...),
+	}
+}
+
+// NewHostsServiceFromDetector 用调用方已经构造好的HostsDetector创建hosts服务，
+// 供按调度配置运行的场景复用同一个检测器（及其缓存、GeoIP数据源），
+// 避免重新构造一个配置不同的检测器
+func NewHostsServiceFromDetector(detector *HostsDetector) *HostsService {
+	return &HostsService{detector: detector}
+}
+
+// Detector 返回服务内部使用的HostsDetector，供main在独立定时任务中复用同一份检测器
+func (hs *HostsService) Detector() *HostsDetector {
+	return hs.detector
+}
+
+// WithResultsProvider 让hosts相关接口改用resultsProvider提供的结果快照，不再对
+// detector触发新的同步检测。按调度配置运行时main应该传入sched.Results
+func (hs *HostsService) WithResultsProvider(p ResultsProvider) *HostsService {
+	hs.resultsProvider = p
+	return hs
+}
+
+// results 返回一份当前结果：配置了resultsProvider时直接用它的快照，
+// 否则退回detector.DetectHosts做一次同步检测
+func (hs *HostsService) results() ([]HostResult, error) {
+	if hs.resultsProvider != nil {
+		return hs.resultsProvider(), nil
 	}
+	return hs.detector.DetectHosts()
 }
 
 // Response 统一响应结构体
@@ -38,8 +74,7 @@ type HostsResponse struct {
 
 // getHosts 获取hosts内容
 func (hs *HostsService) getHosts(c *gin.Context) {
-	// 检测hosts
-	results, err := hs.detector.DetectHosts()
+	results, err := hs.results()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -59,10 +94,13 @@ func (hs *HostsService) getHosts(c *gin.Context) {
 		}
 	}
 
-	// 保存到文件
-	if err := hs.detector.WriteHostsFile(results); err != nil {
-		// 即使保存失败也返回内容，只是记录错误
-		c.Header("X-Save-Error", err.Error())
+	// resultsProvider场景下调度器的Writer已经按配置写过输出文件，这里不重复写，
+	// 避免和调度器的ChangeThreshold/按需重写逻辑打架
+	if hs.resultsProvider == nil {
+		if err := hs.detector.WriteHostsFile(results); err != nil {
+			// 即使保存失败也返回内容，只是记录错误
+			c.Header("X-Save-Error", err.Error())
+		}
 	}
 
 	response := HostsResponse{
@@ -82,8 +120,7 @@ func (hs *HostsService) getHosts(c *gin.Context) {
 
 // getHostsRaw 获取原始hosts文件内容
 func (hs *HostsService) getHostsRaw(c *gin.Context) {
-	// 检测hosts
-	content, err := hs.detector.GetHostsContent()
+	results, err := hs.results()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -91,6 +128,7 @@ func (hs *HostsService) getHostsRaw(c *gin.Context) {
 		})
 		return
 	}
+	content := hs.detector.GenerateHostsContent(results)
 
 	// 设置响应头为纯文本
 	c.Header("Content-Type", "text/plain; charset=utf-8")
@@ -99,6 +137,29 @@ func (hs *HostsService) getHostsRaw(c *gin.Context) {
 	c.String(http.StatusOK, content)
 }
 
+// getCerts 获取每个域名当前选中IP的证书状态
+func (hs *HostsService) getCerts(c *gin.Context) {
+	results, err := hs.results()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "获取证书状态失败: " + err.Error(),
+		})
+		return
+	}
+	certs := CertsFromResults(results)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "获取证书状态成功",
+		Data: gin.H{
+			"certs":      certs,
+			"updated_at": time.Now().Format("2006-01-02 15:04:05"),
+			"total":      len(certs),
+		},
+	})
+}
+
 // getHostsFile 获取已保存的hosts文件内容
 func (hs *HostsService) getHostsFile(c *gin.Context) {
 	// 直接返回文件内容
@@ -155,6 +216,7 @@ func (hs *HostsService) setupRoutes() *gin.Engine {
 		api.GET("/hosts", hs.getHosts)          // 获取hosts（JSON格式）
 		api.GET("/hosts/raw", hs.getHostsRaw)   // 获取hosts原始内容
 		api.GET("/hosts/file", hs.getHostsFile) // 获取已保存的hosts文件
+		api.GET("/certs", hs.getCerts)          // 获取各域名选中IP的证书状态
 	}
 
 	// 根路径重定向到状态页面
@@ -170,6 +232,9 @@ func (hs *HostsService) setupRoutes() *gin.Engine {
 		})
 	})
 
+	// Prometheus指标
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	return router
 }
 
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 这些指标由pkg/hosts在DNS解析、网页抓取、ping缓存和单域名检测流程中上报，
+// 统一通过/metrics端点（见pkg/hosts/service.go）以Prometheus文本格式暴露
+var (
+	DNSQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hosts_server_dns_queries_total",
+		Help: "按解析器和结果统计的DNS查询次数",
+	}, []string{"resolver", "result"})
+
+	WebScrapesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hosts_server_web_scrapes_total",
+		Help: "按结果统计的网页抓取（ipaddress.com）次数",
+	}, []string{"result"})
+
+	DomainFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hosts_server_domain_failures_total",
+		Help: "按域名统计的检测失败次数",
+	}, []string{"domain"})
+
+	PingCacheTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hosts_server_ping_cache_total",
+		Help: "ping结果缓存的命中/未命中次数",
+	}, []string{"result"})
+
+	ProbeRTTSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hosts_server_probe_rtt_seconds",
+		Help:    "探测延迟（秒），按探测方式区分",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	DetectionDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hosts_server_detection_duration_seconds",
+		Help:    "单个域名一次完整检测（processHost）的耗时（秒）",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"domain"})
+
+	LastUpdateTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hosts_server_last_update_timestamp",
+		Help: "每个域名最近一次检测完成时的Unix时间戳",
+	}, []string{"domain"})
+
+	chosenIPGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hosts_server_chosen_ip",
+		Help: "每个域名当前选中的IP，值恒为1，通过ip标签区分",
+	}, []string{"domain", "ip"})
+)
+
+var (
+	chosenIPMu       sync.Mutex
+	chosenIPByDomain = make(map[string]string)
+)
+
+// SetChosenIP 记录domain当前选中的IP。如果该域名之前选中的是另一个IP，
+// 先清除旧IP对应的序列，避免chosenIPGauge里残留已经不再使用的IP
+func SetChosenIP(domain, ip string) {
+	if ip == "" {
+		return
+	}
+
+	chosenIPMu.Lock()
+	defer chosenIPMu.Unlock()
+
+	if prev, ok := chosenIPByDomain[domain]; ok && prev != ip {
+		chosenIPGauge.DeleteLabelValues(domain, prev)
+	}
+	chosenIPByDomain[domain] = ip
+	chosenIPGauge.WithLabelValues(domain, ip).Set(1)
+}
+
+// RecordDetection 记录一次processHost调用的结果：检测耗时、最近更新时间，
+// 以及检测失败时对应域名的失败计数
+func RecordDetection(domain string, duration time.Duration, errMsg string) {
+	DetectionDurationSeconds.WithLabelValues(domain).Observe(duration.Seconds())
+	LastUpdateTimestamp.WithLabelValues(domain).Set(float64(time.Now().Unix()))
+	if errMsg != "" {
+		DomainFailuresTotal.WithLabelValues(domain).Inc()
+	}
+}
+
+// RecordPingCache 记录一次pingCached调用命中了缓存还是发起了新的ICMP探测
+func RecordPingCache(hit bool) {
+	if hit {
+		PingCacheTotal.WithLabelValues("hit").Inc()
+		return
+	}
+	PingCacheTotal.WithLabelValues("miss").Inc()
+}
+
+// ObserveProbeRTT 记录一次探测得到的延迟（单位毫秒），按探测方式分类
+func ObserveProbeRTT(method string, ms float64) {
+	ProbeRTTSeconds.WithLabelValues(method).Observe(ms / 1000)
+}
+
+// RecordDNSQuery 记录一次DNS查询的结果，resolver取Resolver.String()
+func RecordDNSQuery(resolver string, success bool) {
+	DNSQueriesTotal.WithLabelValues(resolver, resultLabel(success)).Inc()
+}
+
+// RecordWebScrape 记录一次网页抓取的结果
+func RecordWebScrape(success bool) {
+	WebScrapesTotal.WithLabelValues(resultLabel(success)).Inc()
+}
+
+func resultLabel(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
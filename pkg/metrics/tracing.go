@@ -0,0 +1,8 @@
+package metrics
+
+import "go.opentelemetry.io/otel"
+
+// Tracer是hosts-server在processHost及其DNS解析/网页抓取/选优各阶段周围打点使用的统一Tracer。
+// 具体的导出器由调用方通过otel.SetTracerProvider配置，未配置时otel默认使用no-op实现，
+// 因此即使不接入任何Trace后端，这里的调用也不会产生额外开销或报错
+var Tracer = otel.Tracer("hosts-server")
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"hosts-server/pkg/hosts"
+)
+
+// DomainSchedule 描述单个域名的检测节奏和切换阈值
+type DomainSchedule struct {
+	Domain string `yaml:"domain" json:"domain"`
+	// Interval 两次检测之间的间隔，使用time.ParseDuration能解析的格式，如 "1h"、"30m"
+	Interval string `yaml:"interval" json:"interval"`
+	// ChangeThreshold 新IP的延迟要比当前沿用的IP至少快多少毫秒才值得切换，否则继续
+	// 沿用旧IP；0表示只要新IP不比旧IP慢就切换，但新IP更慢时仍会沿用旧IP，不是选出
+	// 新IP就无条件写入，这样才能避免在两个延迟接近的候选之间反复横跳
+	ChangeThreshold float64 `yaml:"change_threshold" json:"change_threshold"`
+}
+
+// OutputConfig 描述一种输出格式以及要写入的文件路径
+type OutputConfig struct {
+	// Format 取值见NewWriter支持的格式：hosts/dnsmasq/smartdns/clash/pihole
+	Format string `yaml:"format" json:"format"`
+	Path   string `yaml:"path" json:"path"`
+}
+
+// Config 是调度器的配置，支持YAML和JSON两种格式
+type Config struct {
+	Domains []DomainSchedule `yaml:"domains" json:"domains"`
+	Outputs []OutputConfig   `yaml:"outputs" json:"outputs"`
+	// HistoryDir 保存每个域名历史选中IP的目录，默认scheduler_history
+	HistoryDir string `yaml:"history_dir" json:"history_dir"`
+	// Resolvers 指定DNS解析器列表，格式同hosts.NewResolver（如 udp://1.1.1.1:53、
+	// tls://1.1.1.1:853、https://1.1.1.1/dns-query），不指定则沿用命令行的-resolvers
+	Resolvers []string `yaml:"resolvers" json:"resolvers"`
+	// Mode 指定IPv4/IPv6解析策略(ipv4/ipv6/dual/fastest)，不指定则沿用命令行的-mode
+	Mode string `yaml:"mode" json:"mode"`
+	// GeoIPPath 指定MaxMind GeoLite2 mmdb数据库路径，不指定则沿用命令行的-geoip
+	GeoIPPath string `yaml:"geoip_path" json:"geoip_path"`
+	// IP2RegionPath 指定ip2region xdb数据库路径，不指定则沿用命令行的-ip2region
+	IP2RegionPath string `yaml:"ip2region_path" json:"ip2region_path"`
+	// PreferCountries 指定优先选择的国家/地区代码，不指定则沿用命令行的-prefer-countries
+	PreferCountries []string `yaml:"prefer_countries" json:"prefer_countries"`
+}
+
+// DetectorOptions 把Config中与HostsDetector相关的字段转换为hosts.Option，
+// 供main在构造调度器使用的检测器时与命令行参数合并
+func (c *Config) DetectorOptions() ([]hosts.Option, error) {
+	var opts []hosts.Option
+
+	if len(c.Resolvers) > 0 {
+		resolvers := make([]hosts.Resolver, 0, len(c.Resolvers))
+		for _, addr := range c.Resolvers {
+			r, err := hosts.NewResolver(addr)
+			if err != nil {
+				return nil, fmt.Errorf("调度配置中的resolver %s非法: %v", addr, err)
+			}
+			resolvers = append(resolvers, r)
+		}
+		opts = append(opts, hosts.WithResolvers(resolvers))
+	}
+
+	if c.Mode != "" {
+		mode, err := hosts.ParseMode(c.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("调度配置中的mode非法: %v", err)
+		}
+		opts = append(opts, hosts.WithMode(mode))
+	}
+
+	if c.GeoIPPath != "" {
+		opts = append(opts, hosts.WithGeoIPPath(c.GeoIPPath))
+	}
+	if c.IP2RegionPath != "" {
+		opts = append(opts, hosts.WithIP2RegionPath(c.IP2RegionPath))
+	}
+	if len(c.PreferCountries) > 0 {
+		opts = append(opts, hosts.WithPreferCountries(c.PreferCountries))
+	}
+
+	return opts, nil
+}
+
+// LoadConfig 根据文件扩展名解析YAML或JSON格式的调度配置
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析YAML调度配置失败: %v", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析JSON调度配置失败: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的调度配置格式: %s（仅支持.yaml/.yml/.json）", ext)
+	}
+
+	if cfg.HistoryDir == "" {
+		cfg.HistoryDir = "scheduler_history"
+	}
+
+	return cfg, nil
+}
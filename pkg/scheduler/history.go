@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyWindowSize 每个域名最多保留的历史记录条数，超出的旧记录被丢弃
+const historyWindowSize = 10
+
+// HistoryEntry 记录一次调度检测选中的IP及其延迟
+type HistoryEntry struct {
+	IP        string    `json:"ip"`
+	Ping      float64   `json:"ping"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// History 保存单个域名最近的检测记录，用于判断是否需要切换IP
+type History struct {
+	Domain  string         `json:"domain"`
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// lastIP 返回最近一次记录的IP，没有历史记录时返回空字符串
+func (h *History) lastIP() string {
+	if len(h.Entries) == 0 {
+		return ""
+	}
+	return h.Entries[len(h.Entries)-1].IP
+}
+
+// lastPing 返回最近一次记录的延迟，没有历史记录时返回0
+func (h *History) lastPing() float64 {
+	if len(h.Entries) == 0 {
+		return 0
+	}
+	return h.Entries[len(h.Entries)-1].Ping
+}
+
+func historyPath(dir, domain string) string {
+	return filepath.Join(dir, domain+".json")
+}
+
+// loadHistory 读取某个域名的历史记录，文件不存在时返回一个空的History
+func loadHistory(dir, domain string) (*History, error) {
+	data, err := os.ReadFile(historyPath(dir, domain))
+	if os.IsNotExist(err) {
+		return &History{Domain: domain}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h := &History{}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// saveHistory 追加一条新记录并按historyWindowSize裁剪旧记录，然后写回磁盘
+func saveHistory(dir string, h *History, entry HistoryEntry) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > historyWindowSize {
+		h.Entries = h.Entries[len(h.Entries)-historyWindowSize:]
+	}
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(historyPath(dir, h.Domain), data, 0644)
+}
@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"hosts-server/pkg/hosts"
+)
+
+// Scheduler 按each域名各自的Interval独立检测，检测结果变化超过ChangeThreshold
+// 才会更新该域名当前使用的IP；只有某个域名采用的IP与上次写出时不同，才按配置重新生成所有输出格式
+type Scheduler struct {
+	detector *hosts.HostsDetector
+	config   *Config
+	writers  []Writer
+
+	mu          sync.Mutex
+	results     map[string]hosts.HostResult
+	lastWritten map[string]string // 每个域名上一次实际写入输出文件时的IP，用于判断本次是否需要重写
+}
+
+// New 根据Config创建Scheduler，提前构造好所有输出Writer以便尽早发现配置错误
+func New(detector *hosts.HostsDetector, config *Config) (*Scheduler, error) {
+	writers := make([]Writer, 0, len(config.Outputs))
+	for _, o := range config.Outputs {
+		w, err := NewWriter(o.Format, o.Path)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+
+	return &Scheduler{
+		detector:    detector,
+		config:      config,
+		writers:     writers,
+		results:     make(map[string]hosts.HostResult, len(config.Domains)),
+		lastWritten: make(map[string]string, len(config.Domains)),
+	}, nil
+}
+
+// Run 为每个域名启动一个按各自Interval运行的goroutine，stop关闭时全部退出
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	for _, d := range s.config.Domains {
+		interval, err := time.ParseDuration(d.Interval)
+		if err != nil {
+			log.Printf("调度配置中域名 %s 的interval非法(%s): %v，跳过该域名", d.Domain, d.Interval, err)
+			continue
+		}
+
+		go s.runDomain(d, interval, stop)
+	}
+}
+
+// runDomain 立即检测一次，随后按interval循环检测，直到stop被关闭
+func (s *Scheduler) runDomain(d DomainSchedule, interval time.Duration, stop <-chan struct{}) {
+	s.checkDomain(d)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkDomain(d)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkDomain 检测单个域名，并依据ChangeThreshold决定是否采用新的检测结果
+func (s *Scheduler) checkDomain(d DomainSchedule) {
+	result := s.detector.DetectOne(d.Domain)
+	if result.Error != "" {
+		log.Printf("调度检测域名 %s 失败: %s", d.Domain, result.Error)
+		return
+	}
+
+	hist, err := loadHistory(s.config.HistoryDir, d.Domain)
+	if err != nil {
+		log.Printf("读取域名 %s 的历史记录失败: %v", d.Domain, err)
+		hist = &History{Domain: d.Domain}
+	}
+
+	ip := bestIP(result)
+	ping := bestPing(result)
+	// bestIP/bestPing优先读IP/Ping字段，只有IP为空时才落到IPv6/Ping6，这里记下这次
+	// 究竟是哪个字段，revert时要写回同一个字段，不能不管三七二十一都写result.IP——
+	// 否则ipv6/fastest模式下回退的v6地址会被塞进IP字段，IPv6字段还留着本该被丢弃的
+	// 新地址，hostsLines就会给同一个域名吐出两行
+	usedV4 := result.IP != ""
+	if hist.lastIP() != "" && hist.lastIP() != ip {
+		// 新IP只有比沿用的IP快出ChangeThreshold毫秒以上才值得切换，
+		// 否则继续沿用旧结果，避免在延迟接近的候选之间反复横跳
+		if hist.lastPing()-ping < d.ChangeThreshold {
+			if usedV4 {
+				result.IP = hist.lastIP()
+				result.Ping = hist.lastPing()
+			} else {
+				result.IPv6 = hist.lastIP()
+				result.Ping6 = hist.lastPing()
+			}
+			ip = hist.lastIP()
+			ping = hist.lastPing()
+		}
+	}
+
+	if err := saveHistory(s.config.HistoryDir, hist, HistoryEntry{
+		IP:        ip,
+		Ping:      ping,
+		CheckedAt: time.Now(),
+	}); err != nil {
+		log.Printf("保存域名 %s 的历史记录失败: %v", d.Domain, err)
+	}
+
+	s.mu.Lock()
+	s.results[d.Domain] = result
+	changed := s.lastWritten[d.Domain] != ip
+	if changed {
+		s.lastWritten[d.Domain] = ip
+	}
+	s.mu.Unlock()
+
+	if !changed {
+		// 选中的IP和上次写入输出文件时一致，跳过重写，避免给下游配置带来无意义的变动
+		return
+	}
+
+	s.writeOutputs()
+}
+
+// writeOutputs 把当前已知的全部结果按配置的每种格式写入对应文件
+func (s *Scheduler) writeOutputs() {
+	s.mu.Lock()
+	results := make([]hosts.HostResult, 0, len(s.results))
+	for _, r := range s.results {
+		results = append(results, r)
+	}
+	s.mu.Unlock()
+
+	for _, w := range s.writers {
+		if err := w.Write(results); err != nil {
+			log.Printf("写入%s格式输出失败: %v", w.Name(), err)
+		}
+	}
+}
+
+// Results 返回当前已知的全部检测结果快照，供外部查询使用
+func (s *Scheduler) Results() []hosts.HostResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]hosts.HostResult, 0, len(s.results))
+	for _, r := range s.results {
+		results = append(results, r)
+	}
+	return results
+}
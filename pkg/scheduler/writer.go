@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"hosts-server/pkg/hosts"
+)
+
+// Writer 把一批检测结果按某种格式写入目标文件，由NewWriter按配置中的format创建
+type Writer interface {
+	Write(results []hosts.HostResult) error
+	Name() string
+}
+
+// bestIP 优先返回结果中的IPv4地址，仅在IPv4缺失时退回IPv6，
+// 和hosts_detector.go中hostsLines对IPv4的优先级保持一致
+func bestIP(r hosts.HostResult) string {
+	if r.IP != "" {
+		return r.IP
+	}
+	return r.IPv6
+}
+
+// bestPing 返回bestIP选中的那个IP对应的探测延迟：IPv6Only模式或PreferFastest选中IPv6时
+// HostResult.IP为空、Ping为0，延迟只记录在Ping6里（DualStack则两者都有），
+// 所以必须跟着bestIP一起选字段，不能固定只读Ping
+func bestPing(r hosts.HostResult) float64 {
+	if r.IP != "" {
+		return r.Ping
+	}
+	return r.Ping6
+}
+
+// writeLines 是各格式共用的骨架：跳过检测失败或没有可用IP的域名，
+// 其余每个域名交给format生成一行，最后整体写入path
+func writeLines(path string, results []hosts.HostResult, format func(domain, ip string) string) error {
+	var lines []string
+	for _, r := range results {
+		ip := bestIP(r)
+		if r.Error != "" || ip == "" {
+			continue
+		}
+		lines = append(lines, format(r.Domain, ip))
+	}
+
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// HostsWriter 生成标准/etc/hosts格式
+type HostsWriter struct {
+	Path string
+}
+
+func (w *HostsWriter) Name() string { return "hosts" }
+
+func (w *HostsWriter) Write(results []hosts.HostResult) error {
+	header := fmt.Sprintf("# Generated by hosts-server scheduler at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	return writeLinesWithHeader(w.Path, header, results, func(domain, ip string) string {
+		return fmt.Sprintf("%-30s %s", ip, domain)
+	})
+}
+
+// DnsmasqWriter 生成dnsmasq的address=配置片段
+type DnsmasqWriter struct {
+	Path string
+}
+
+func (w *DnsmasqWriter) Name() string { return "dnsmasq" }
+
+func (w *DnsmasqWriter) Write(results []hosts.HostResult) error {
+	return writeLines(w.Path, results, func(domain, ip string) string {
+		return fmt.Sprintf("address=/%s/%s", domain, ip)
+	})
+}
+
+// SmartDNSWriter 生成SmartDNS的address配置片段
+type SmartDNSWriter struct {
+	Path string
+}
+
+func (w *SmartDNSWriter) Name() string { return "smartdns" }
+
+func (w *SmartDNSWriter) Write(results []hosts.HostResult) error {
+	return writeLines(w.Path, results, func(domain, ip string) string {
+		return fmt.Sprintf("address /%s/%s", domain, ip)
+	})
+}
+
+// ClashWriter 生成Clash配置中可直接合并的hosts字段（YAML片段）
+type ClashWriter struct {
+	Path string
+}
+
+func (w *ClashWriter) Name() string { return "clash" }
+
+func (w *ClashWriter) Write(results []hosts.HostResult) error {
+	var b strings.Builder
+	b.WriteString("hosts:\n")
+	for _, r := range results {
+		ip := bestIP(r)
+		if r.Error != "" || ip == "" {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %s: %s\n", r.Domain, ip))
+	}
+	return os.WriteFile(w.Path, []byte(b.String()), 0644)
+}
+
+// PiholeWriter 生成Pi-hole自定义DNS记录使用的hosts格式（行格式与HostsWriter一致，但不写头注释）
+type PiholeWriter struct {
+	Path string
+}
+
+func (w *PiholeWriter) Name() string { return "pihole" }
+
+func (w *PiholeWriter) Write(results []hosts.HostResult) error {
+	return writeLines(w.Path, results, func(domain, ip string) string {
+		return fmt.Sprintf("%-30s %s", ip, domain)
+	})
+}
+
+// writeLinesWithHeader 和writeLines相同，但在内容前加一行头注释
+func writeLinesWithHeader(path, header string, results []hosts.HostResult, format func(domain, ip string) string) error {
+	var lines []string
+	for _, r := range results {
+		ip := bestIP(r)
+		if r.Error != "" || ip == "" {
+			continue
+		}
+		lines = append(lines, format(r.Domain, ip))
+	}
+
+	content := header + strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// NewWriter 根据format字符串创建对应的Writer，format不区分大小写
+func NewWriter(format, path string) (Writer, error) {
+	switch strings.ToLower(format) {
+	case "hosts":
+		return &HostsWriter{Path: path}, nil
+	case "dnsmasq":
+		return &DnsmasqWriter{Path: path}, nil
+	case "smartdns":
+		return &SmartDNSWriter{Path: path}, nil
+	case "clash":
+		return &ClashWriter{Path: path}, nil
+	case "pihole":
+		return &PiholeWriter{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("不支持的输出格式: %s", format)
+	}
+}